@@ -0,0 +1,108 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/render/graph"
+	"github.com/weaveworks/scope/report"
+)
+
+// adjacencyOf converts nodes' per-node Adjacency lists into the plain
+// adjacency map the render/graph algorithms operate on.
+func adjacencyOf(nodes RenderableNodes) map[string][]string {
+	adjacency := make(map[string][]string, len(nodes))
+	for id, node := range nodes {
+		adjacency[id] = append([]string(nil), node.Adjacency...)
+	}
+	return adjacency
+}
+
+// LargestComponent is a Renderer which keeps only the largest
+// weakly-connected component of its input, dropping the singleton and
+// small islands that noise tends to create.
+type LargestComponent struct {
+	Renderer
+}
+
+// Render implements Renderer.
+func (l LargestComponent) Render(rpt report.Report) RenderableNodes {
+	return largestComponentOf(l.Renderer.Render(rpt))
+}
+
+// RenderContext implements ContextRenderer, reusing l.Renderer's cached
+// output via ctx rather than calling its plain Render.
+func (l LargestComponent) RenderContext(ctx RenderContext) RenderableNodes {
+	return largestComponentOf(renderWithContext(l.Renderer, ctx))
+}
+
+// EdgeMetadataContext implements ContextRenderer.
+func (l LargestComponent) EdgeMetadataContext(ctx RenderContext, localID, remoteID string) report.EdgeMetadata {
+	return edgeMetadataWithContext(l.Renderer, ctx, localID, remoteID)
+}
+
+func largestComponentOf(nodes RenderableNodes) RenderableNodes {
+	components := graph.ConnectedComponents(adjacencyOf(nodes))
+
+	var largest []string
+	var largestMinID string
+	for _, component := range components {
+		minID := minID(component)
+		if len(component) > len(largest) || (len(component) == len(largest) && minID < largestMinID) {
+			largest, largestMinID = component, minID
+		}
+	}
+
+	output := make(RenderableNodes, len(largest))
+	for _, id := range largest {
+		output[id] = nodes[id]
+	}
+	return output
+}
+
+// minID returns the lexicographically smallest ID in ids. It's used to
+// break ties between same-size components deterministically: components
+// come from graph.ConnectedComponents, whose internal order depends on
+// map iteration, so comparing component sizes alone would make the
+// renderer's output flap between equally-sized components across runs of
+// the same input.
+func minID(ids []string) string {
+	min := ids[0]
+	for _, id := range ids[1:] {
+		if id < min {
+			min = id
+		}
+	}
+	return min
+}
+
+// NeighborhoodRenderer is a Renderer which keeps only the nodes within
+// Depth hops of Center (inclusive), for a UI "focus mode" on one node.
+type NeighborhoodRenderer struct {
+	Renderer
+	Center string
+	Depth  int
+}
+
+// Render implements Renderer.
+func (n NeighborhoodRenderer) Render(rpt report.Report) RenderableNodes {
+	return n.neighborhoodOf(n.Renderer.Render(rpt))
+}
+
+// RenderContext implements ContextRenderer, reusing n.Renderer's cached
+// output via ctx rather than calling its plain Render.
+func (n NeighborhoodRenderer) RenderContext(ctx RenderContext) RenderableNodes {
+	return n.neighborhoodOf(renderWithContext(n.Renderer, ctx))
+}
+
+// EdgeMetadataContext implements ContextRenderer.
+func (n NeighborhoodRenderer) EdgeMetadataContext(ctx RenderContext, localID, remoteID string) report.EdgeMetadata {
+	return edgeMetadataWithContext(n.Renderer, ctx, localID, remoteID)
+}
+
+func (n NeighborhoodRenderer) neighborhoodOf(nodes RenderableNodes) RenderableNodes {
+	ids := graph.Neighborhood(adjacencyOf(nodes), n.Center, n.Depth)
+
+	output := make(RenderableNodes, len(ids))
+	for _, id := range ids {
+		output[id] = nodes[id]
+	}
+	return output
+}
@@ -0,0 +1,136 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestOnlyConnectedDropsIsolatedNode(t *testing.T) {
+	nodes := RenderableNodes{"a": {ID: "a"}}
+
+	output := OnlyConnected(nodes)
+
+	if _, ok := output["a"]; ok {
+		t.Errorf("expected isolated node %q to be dropped", "a")
+	}
+}
+
+func TestOnlyConnectedKeepsSelfLoop(t *testing.T) {
+	nodes := RenderableNodes{"a": {ID: "a", Adjacency: report.MakeIDList().Add("a")}}
+
+	output := OnlyConnected(nodes)
+
+	if _, ok := output["a"]; !ok {
+		t.Errorf("expected self-looping node %q to be kept", "a")
+	}
+}
+
+func TestOnlyConnectedKeepsOneDirectionalEdge(t *testing.T) {
+	nodes := RenderableNodes{
+		"a": {ID: "a", Adjacency: report.MakeIDList().Add("b")},
+		"b": {ID: "b"},
+	}
+
+	output := OnlyConnected(nodes)
+
+	if _, ok := output["a"]; !ok {
+		t.Errorf("expected node %q to be kept", "a")
+	}
+	if _, ok := output["b"]; !ok {
+		t.Errorf("expected node %q, reachable only via a's one-directional edge, to be kept", "b")
+	}
+}
+
+func TestLargestComponentKeepsBiggest(t *testing.T) {
+	nodes := RenderableNodes{
+		"a": {ID: "a", Adjacency: report.MakeIDList().Add("b")},
+		"b": {ID: "b"},
+		"c": {ID: "c", Adjacency: report.MakeIDList().Add("d")},
+		"d": {ID: "d", Adjacency: report.MakeIDList().Add("e")},
+		"e": {ID: "e"},
+	}
+	component := LargestComponent{Renderer: staticRenderer{nodes: nodes}}
+
+	output := component.Render(report.Report{})
+
+	for _, id := range []string{"c", "d", "e"} {
+		if _, ok := output[id]; !ok {
+			t.Errorf("expected node %q, part of the largest component, to be kept", id)
+		}
+	}
+	if len(output) != 3 {
+		t.Errorf("expected only the 3-node component to survive, got %d nodes", len(output))
+	}
+}
+
+func TestLargestComponentBreaksTiesByMinID(t *testing.T) {
+	// Two same-size components: {x,y} and {a,b}. Regardless of which
+	// order graph.ConnectedComponents happens to return them in, the one
+	// containing the lexicographically smallest ID should always win, so
+	// the render is stable across runs of the same input.
+	nodes := RenderableNodes{
+		"x": {ID: "x", Adjacency: report.MakeIDList().Add("y")},
+		"y": {ID: "y"},
+		"a": {ID: "a", Adjacency: report.MakeIDList().Add("b")},
+		"b": {ID: "b"},
+	}
+	component := LargestComponent{Renderer: staticRenderer{nodes: nodes}}
+
+	output := component.Render(report.Report{})
+
+	if _, ok := output["a"]; !ok {
+		t.Errorf("expected component {a,b}, which has the smallest ID, to win the tie")
+	}
+	if len(output) != 2 {
+		t.Errorf("expected exactly one 2-node component to survive, got %d nodes", len(output))
+	}
+}
+
+func neighborhoodFixture() RenderableNodes {
+	// center - a - b, center - c
+	return RenderableNodes{
+		"center": {ID: "center", Adjacency: report.MakeIDList().Add("a").Add("c")},
+		"a":      {ID: "a", Adjacency: report.MakeIDList().Add("b")},
+		"b":      {ID: "b"},
+		"c":      {ID: "c"},
+	}
+}
+
+func TestNeighborhoodRendererDepthZeroKeepsOnlyCenter(t *testing.T) {
+	neighborhood := NeighborhoodRenderer{Renderer: staticRenderer{nodes: neighborhoodFixture()}, Center: "center", Depth: 0}
+
+	output := neighborhood.Render(report.Report{})
+
+	if _, ok := output["center"]; !ok {
+		t.Errorf("expected center node to be kept")
+	}
+	if len(output) != 1 {
+		t.Errorf("expected only the center node at depth 0, got %d nodes", len(output))
+	}
+}
+
+func TestNeighborhoodRendererDepthOneKeepsImmediateNeighbors(t *testing.T) {
+	neighborhood := NeighborhoodRenderer{Renderer: staticRenderer{nodes: neighborhoodFixture()}, Center: "center", Depth: 1}
+
+	output := neighborhood.Render(report.Report{})
+
+	for _, id := range []string{"center", "a", "c"} {
+		if _, ok := output[id]; !ok {
+			t.Errorf("expected node %q within 1 hop of center to be kept", id)
+		}
+	}
+	if _, ok := output["b"]; ok {
+		t.Errorf("expected node %q, 2 hops from center, to be dropped at depth 1", "b")
+	}
+}
+
+func TestNeighborhoodRendererUnknownCenter(t *testing.T) {
+	neighborhood := NeighborhoodRenderer{Renderer: staticRenderer{nodes: neighborhoodFixture()}, Center: "nope", Depth: 2}
+
+	output := neighborhood.Render(report.Report{})
+
+	if len(output) != 0 {
+		t.Errorf("expected no nodes for an unknown center, got %d", len(output))
+	}
+}
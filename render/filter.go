@@ -0,0 +1,204 @@
+package render
+
+import "github.com/weaveworks/scope/report"
+
+// FilterFunc decides whether a RenderableNode should be kept by a Filter.
+type FilterFunc func(RenderableNode) bool
+
+// filteredID is the ID of the pseudo node a collapsing Filter synthesizes
+// to stand in for everything it dropped.
+const filteredID = "FILTERED"
+
+// Filter is a Renderer which keeps only the RenderableNodes for which
+// Predicate returns true, and fixes up the adjacencies of the survivors.
+// It is the general form that FilterPseudo, FilterByLabelSelector,
+// FilterByTopology, FilterNoise and FilterByOrigin below are built from,
+// so the UI gets a real query language over a rendered graph instead of
+// needing a bespoke Renderer type for every kind of filter.
+type Filter struct {
+	Renderer
+	Predicate FilterFunc
+
+	// CollapseDangling, if true, replaces adjacencies to a node this
+	// Filter dropped with a single synthesized pseudo node ("FILTERED")
+	// per source node, rather than just dropping the adjacency (the
+	// default, and the behaviour of the old FilterUnconnected).
+	CollapseDangling bool
+}
+
+// MakeFilter wraps r so that only nodes matching predicate survive.
+// Adjacencies to dropped nodes are dropped.
+func MakeFilter(r Renderer, predicate FilterFunc) Renderer {
+	return Filter{Renderer: r, Predicate: predicate}
+}
+
+// MakeCollapsingFilter is like MakeFilter, but adjacencies to a dropped
+// node are collapsed into a synthesized pseudo "filtered" node instead of
+// being dropped, so the UI can still show that something was hidden there.
+func MakeCollapsingFilter(r Renderer, predicate FilterFunc) Renderer {
+	return Filter{Renderer: r, Predicate: predicate, CollapseDangling: true}
+}
+
+// Render implements Renderer.
+func (f Filter) Render(rpt report.Report) RenderableNodes {
+	return f.render(f.Renderer.Render(rpt))
+}
+
+// EdgeMetadata implements Renderer. The synthesized "filtered" node has no
+// real edges of its own, so it never contributes metadata.
+func (f Filter) EdgeMetadata(rpt report.Report, localID, remoteID string) report.EdgeMetadata {
+	if localID == filteredID || remoteID == filteredID {
+		return report.EdgeMetadata{}
+	}
+	return f.Renderer.EdgeMetadata(rpt, localID, remoteID)
+}
+
+// render does the filtering work for both Render and RenderContext, given
+// whatever already-rendered input the caller obtained from f.Renderer.
+func (f Filter) render(input RenderableNodes) RenderableNodes {
+	output := RenderableNodes{}
+	for id, node := range input {
+		if f.Predicate(node) {
+			output[id] = node
+		}
+	}
+
+	for id, node := range output {
+		kept := report.MakeIDList()
+		dropped := false
+		for _, adjID := range node.Adjacency {
+			if _, ok := output[adjID]; ok {
+				kept = kept.Add(adjID)
+			} else {
+				dropped = true
+			}
+		}
+
+		if dropped && f.CollapseDangling {
+			kept = kept.Add(filteredID)
+			if _, ok := output[filteredID]; !ok {
+				output[filteredID] = RenderableNode{ID: filteredID, Pseudo: true}
+			}
+		}
+
+		node.Adjacency = kept
+		output[id] = node
+	}
+
+	return output
+}
+
+// RenderContext implements ContextRenderer, reusing f.Renderer's cached
+// output via ctx rather than calling its plain Render.
+func (f Filter) RenderContext(ctx RenderContext) RenderableNodes {
+	return f.render(renderWithContext(f.Renderer, ctx))
+}
+
+// EdgeMetadataContext implements ContextRenderer.
+func (f Filter) EdgeMetadataContext(ctx RenderContext, localID, remoteID string) report.EdgeMetadata {
+	if localID == filteredID || remoteID == filteredID {
+		return report.EdgeMetadata{}
+	}
+	return edgeMetadataWithContext(f.Renderer, ctx, localID, remoteID)
+}
+
+// And returns a FilterFunc that keeps a node only when every one of fns does.
+func And(fns ...FilterFunc) FilterFunc {
+	return func(node RenderableNode) bool {
+		for _, fn := range fns {
+			if !fn(node) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a FilterFunc that keeps a node when any one of fns does.
+func Or(fns ...FilterFunc) FilterFunc {
+	return func(node RenderableNode) bool {
+		for _, fn := range fns {
+			if fn(node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a FilterFunc that keeps a node when fn would drop it.
+func Not(fn FilterFunc) FilterFunc {
+	return func(node RenderableNode) bool { return !fn(node) }
+}
+
+// FilterPseudo drops pseudo nodes.
+func FilterPseudo(node RenderableNode) bool {
+	return !node.Pseudo
+}
+
+// FilterByOrigin keeps only nodes whose Origins include hostNodeID.
+func FilterByOrigin(hostNodeID string) FilterFunc {
+	return func(node RenderableNode) bool {
+		for _, origin := range node.Origins {
+			if origin == hostNodeID {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FilterByTopology keeps only nodes whose ID is in ids, the set of node IDs
+// belonging to a single topology. RenderableNode doesn't carry its source
+// topology once multiple topologies have been combined (e.g. by Reduce), so
+// the caller supplies ids itself - typically the keys of a single LeafMap's
+// own Render output, gathered before it's merged with anything else.
+func FilterByTopology(ids map[string]struct{}) FilterFunc {
+	return func(node RenderableNode) bool {
+		_, ok := ids[node.ID]
+		return ok
+	}
+}
+
+// FilterNoise drops nodes whose EdgeMetadata doesn't clear minBytes bytes
+// or minPackets packets, so that lone, barely-active nodes don't clutter
+// the graph.
+func FilterNoise(minBytes, minPackets uint64) FilterFunc {
+	return func(node RenderableNode) bool {
+		md := node.EdgeMetadata
+		bytes := md.EgressByteCount + md.IngressByteCount
+		packets := md.EgressPacketCount + md.IngressPacketCount
+		return bytes >= minBytes || packets >= minPackets
+	}
+}
+
+// LabelSelector is a single key=value, or key in (a, b, ...), match over a
+// node's metadata, modelled on Kubernetes label selectors.
+type LabelSelector struct {
+	Key    string
+	Values []string
+	Negate bool // true for a "key notin (...)" / "key != value" selector
+}
+
+// Matches reports whether value satisfies the selector.
+func (s LabelSelector) Matches(value string) bool {
+	for _, v := range s.Values {
+		if v == value {
+			return !s.Negate
+		}
+	}
+	return s.Negate
+}
+
+// FilterByLabelSelector keeps only nodes whose metadata satisfies every
+// one of selectors.
+func FilterByLabelSelector(selectors ...LabelSelector) FilterFunc {
+	return func(node RenderableNode) bool {
+		for _, s := range selectors {
+			if !s.Matches(node.Metadata[s.Key]) {
+				return false
+			}
+		}
+		return true
+	}
+}
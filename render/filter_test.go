@@ -0,0 +1,159 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func filterFixture() RenderableNodes {
+	return RenderableNodes{
+		"a": {ID: "a", Adjacency: report.MakeIDList().Add("b").Add("c")},
+		"b": {ID: "b", Adjacency: report.MakeIDList().Add("a")},
+		"c": {ID: "c", Pseudo: true},
+	}
+}
+
+func TestFilterDropsDanglingAdjacencyByDefault(t *testing.T) {
+	base := staticRenderer{nodes: filterFixture()}
+	filtered := MakeFilter(base, FilterPseudo)
+
+	output := filtered.Render(report.Report{})
+
+	if _, ok := output["c"]; ok {
+		t.Fatalf("expected pseudo node %q to be dropped", "c")
+	}
+	if got := output["a"].Adjacency; len(got) != 1 || got[0] != "b" {
+		t.Errorf("Adjacency = %v, want [b]", got)
+	}
+}
+
+func TestFilterCollapsesDanglingAdjacency(t *testing.T) {
+	base := staticRenderer{nodes: filterFixture()}
+	filtered := MakeCollapsingFilter(base, FilterPseudo)
+
+	output := filtered.Render(report.Report{})
+
+	node, ok := output["a"]
+	if !ok {
+		t.Fatalf("expected node %q to survive", "a")
+	}
+	found := false
+	for _, id := range node.Adjacency {
+		if id == filteredID {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Adjacency = %v, want it to include %q", node.Adjacency, filteredID)
+	}
+	if _, ok := output[filteredID]; !ok {
+		t.Errorf("expected a synthesized %q node", filteredID)
+	}
+}
+
+// countingRenderer wraps staticRenderer and counts how many times Render
+// is called, so a test can assert that a caching layer above it actually
+// avoided a re-render rather than just not crashing.
+type countingRenderer struct {
+	staticRenderer
+	calls *int
+}
+
+func (c countingRenderer) Render(rpt report.Report) RenderableNodes {
+	*c.calls++
+	return c.staticRenderer.Render(rpt)
+}
+
+func TestFilterRenderContextReusesUnderlyingCache(t *testing.T) {
+	calls := 0
+	base := countingRenderer{staticRenderer: staticRenderer{nodes: filterFixture()}, calls: &calls}
+	m := MakeMap(identityMapFunc, base)
+	filtered := MakeFilter(m, FilterPseudo)
+
+	cr, ok := filtered.(ContextRenderer)
+	if !ok {
+		t.Fatalf("Filter does not implement ContextRenderer")
+	}
+
+	ctx := NewRenderContext(report.Report{})
+	cr.RenderContext(ctx)
+	for i := 0; i < 5; i++ {
+		cr.EdgeMetadataContext(ctx, "a", "b")
+	}
+
+	if calls != 1 {
+		t.Errorf("underlying Renderer.Render called %d times via a shared RenderContext, want 1", calls)
+	}
+}
+
+func TestFilterByOrigin(t *testing.T) {
+	nodes := RenderableNodes{
+		"a": {ID: "a", Origins: report.MakeIDList().Add("host1")},
+		"b": {ID: "b", Origins: report.MakeIDList().Add("host2")},
+	}
+	predicate := FilterByOrigin("host1")
+
+	if !predicate(nodes["a"]) {
+		t.Errorf("expected node %q with matching origin to be kept", "a")
+	}
+	if predicate(nodes["b"]) {
+		t.Errorf("expected node %q with non-matching origin to be dropped", "b")
+	}
+}
+
+func TestFilterByTopology(t *testing.T) {
+	ids := map[string]struct{}{"a": {}}
+	predicate := FilterByTopology(ids)
+
+	if !predicate(RenderableNode{ID: "a"}) {
+		t.Errorf("expected node %q in ids to be kept", "a")
+	}
+	if predicate(RenderableNode{ID: "b"}) {
+		t.Errorf("expected node %q not in ids to be dropped", "b")
+	}
+}
+
+func TestFilterNoise(t *testing.T) {
+	predicate := FilterNoise(100, 10)
+
+	quiet := RenderableNode{EdgeMetadata: report.EdgeMetadata{EgressByteCount: 5}}
+	loud := RenderableNode{EdgeMetadata: report.EdgeMetadata{EgressByteCount: 200}}
+
+	if predicate(quiet) {
+		t.Errorf("expected quiet node to be dropped")
+	}
+	if !predicate(loud) {
+		t.Errorf("expected loud node to be kept")
+	}
+}
+
+func TestFilterByLabelSelector(t *testing.T) {
+	selector := LabelSelector{Key: "role", Values: []string{"server"}}
+	predicate := FilterByLabelSelector(selector)
+
+	server := RenderableNode{Metadata: map[string]string{"role": "server"}}
+	client := RenderableNode{Metadata: map[string]string{"role": "client"}}
+
+	if !predicate(server) {
+		t.Errorf("expected server node to be kept")
+	}
+	if predicate(client) {
+		t.Errorf("expected client node to be dropped")
+	}
+}
+
+func TestAndOrNot(t *testing.T) {
+	always := func(RenderableNode) bool { return true }
+	never := func(RenderableNode) bool { return false }
+
+	if And(always, never)(RenderableNode{}) {
+		t.Errorf("And(true, false) should be false")
+	}
+	if !Or(always, never)(RenderableNode{}) {
+		t.Errorf("Or(true, false) should be true")
+	}
+	if Not(always)(RenderableNode{}) {
+		t.Errorf("Not(true) should be false")
+	}
+}
@@ -0,0 +1,205 @@
+// Package graph implements a handful of generic graph algorithms used to
+// build Renderers that reason about the shape of a rendered topology -
+// which nodes are reachable from which, and how they cluster - rather
+// than just their individual metadata. Algorithms here work over a plain
+// adjacency map (node ID -> neighbor IDs) so that they have no dependency
+// on the render package; render adapts RenderableNodes into that shape and
+// back.
+package graph
+
+// ConnectedComponents partitions the node IDs present in adjacency into
+// their weakly-connected components, i.e. treating every edge as
+// undirected. adjacency need not be symmetric: an edge recorded only as
+// a->b is still treated as connecting a and b.
+func ConnectedComponents(adjacency map[string][]string) [][]string {
+	undirected := symmetric(adjacency)
+	visited := map[string]bool{}
+	var components [][]string
+
+	for id := range undirected {
+		if visited[id] {
+			continue
+		}
+		var component []string
+		queue := []string{id}
+		visited[id] = true
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			component = append(component, current)
+			for _, neighbor := range undirected[current] {
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					queue = append(queue, neighbor)
+				}
+			}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// ShortestPath returns the node IDs on a shortest (fewest-hops) path from
+// src to dst, inclusive of both ends, or nil if src, dst or a path
+// between them doesn't exist.
+func ShortestPath(adjacency map[string][]string, src, dst string) []string {
+	undirected := symmetric(adjacency)
+	if _, ok := undirected[src]; !ok {
+		return nil
+	}
+	if src == dst {
+		return []string{src}
+	}
+
+	prev := map[string]string{src: src}
+	queue := []string{src}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, neighbor := range undirected[current] {
+			if _, seen := prev[neighbor]; seen {
+				continue
+			}
+			prev[neighbor] = current
+			if neighbor == dst {
+				return reconstruct(prev, src, dst)
+			}
+			queue = append(queue, neighbor)
+		}
+	}
+	return nil
+}
+
+func reconstruct(prev map[string]string, src, dst string) []string {
+	path := []string{dst}
+	for path[len(path)-1] != src {
+		path = append(path, prev[path[len(path)-1]])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Neighborhood returns the node IDs within depth hops of center (inclusive
+// of center itself), treating adjacency as undirected.
+func Neighborhood(adjacency map[string][]string, center string, depth int) []string {
+	undirected := symmetric(adjacency)
+	if _, ok := undirected[center]; !ok {
+		return nil
+	}
+
+	visited := map[string]bool{center: true}
+	frontier := []string{center}
+	for i := 0; i < depth && len(frontier) > 0; i++ {
+		var next []string
+		for _, id := range frontier {
+			for _, neighbor := range undirected[id] {
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					next = append(next, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	ids := make([]string, 0, len(visited))
+	for id := range visited {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// TopologicalLayers buckets the node IDs in adjacency into layers for a
+// hierarchical layout hint: layer 0 holds every node with no incoming
+// edge, layer 1 holds everything reachable from a layer-0 node that isn't
+// already placed, and so on. adjacency is not required to be acyclic - a
+// cycle with no entry point is broken by placing an arbitrary member of it
+// first.
+func TopologicalLayers(adjacency map[string][]string) [][]string {
+	hasIncoming := map[string]bool{}
+	nodes := map[string]bool{}
+	for id, neighbors := range adjacency {
+		nodes[id] = true
+		for _, n := range neighbors {
+			nodes[n] = true
+			hasIncoming[n] = true
+		}
+	}
+
+	placed := map[string]bool{}
+	var layers [][]string
+	frontier := roots(nodes, hasIncoming)
+
+	for len(placed) < len(nodes) {
+		if len(frontier) == 0 {
+			frontier = []string{anyUnplaced(nodes, placed)}
+		}
+
+		var layer []string
+		seen := map[string]bool{}
+		for _, id := range frontier {
+			if placed[id] || seen[id] {
+				continue
+			}
+			seen[id] = true
+			placed[id] = true
+			layer = append(layer, id)
+		}
+		if len(layer) == 0 {
+			break
+		}
+		layers = append(layers, layer)
+
+		nextSeen := map[string]bool{}
+		var next []string
+		for _, id := range layer {
+			for _, neighbor := range adjacency[id] {
+				if !placed[neighbor] && !nextSeen[neighbor] {
+					nextSeen[neighbor] = true
+					next = append(next, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return layers
+}
+
+func roots(nodes map[string]bool, hasIncoming map[string]bool) []string {
+	var result []string
+	for id := range nodes {
+		if !hasIncoming[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func anyUnplaced(nodes map[string]bool, placed map[string]bool) string {
+	for id := range nodes {
+		if !placed[id] {
+			return id
+		}
+	}
+	return ""
+}
+
+// symmetric returns adjacency with every edge mirrored, so a->b implies
+// b->a, and every node mentioned (as either source or destination) has an
+// entry even if it has no outgoing edges of its own.
+func symmetric(adjacency map[string][]string) map[string][]string {
+	result := map[string][]string{}
+	for id, neighbors := range adjacency {
+		if _, ok := result[id]; !ok {
+			result[id] = nil
+		}
+		for _, neighbor := range neighbors {
+			result[id] = append(result[id], neighbor)
+			result[neighbor] = append(result[neighbor], id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package graph
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestShortestPath(t *testing.T) {
+	// a - b - c - d, plus a direct b - d shortcut.
+	adjacency := map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+		"c": {"d"},
+		"d": {"b"},
+	}
+
+	// The shortcut means the shortest path is a-b-d, not a-b-c-d.
+	got := ShortestPath(adjacency, "a", "d")
+	want := []string{"a", "b", "d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ShortestPath(a, d) = %v, want %v", got, want)
+	}
+}
+
+func TestShortestPathSameNode(t *testing.T) {
+	adjacency := map[string][]string{"a": {"b"}}
+
+	got := ShortestPath(adjacency, "a", "a")
+	want := []string{"a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ShortestPath(a, a) = %v, want %v", got, want)
+	}
+}
+
+func TestShortestPathNoPath(t *testing.T) {
+	adjacency := map[string][]string{"a": {"b"}, "c": {"d"}}
+
+	if got := ShortestPath(adjacency, "a", "c"); got != nil {
+		t.Errorf("ShortestPath(a, c) = %v, want nil", got)
+	}
+}
+
+func TestShortestPathUnknownNode(t *testing.T) {
+	adjacency := map[string][]string{"a": {"b"}}
+
+	if got := ShortestPath(adjacency, "z", "a"); got != nil {
+		t.Errorf("ShortestPath(z, a) = %v, want nil", got)
+	}
+}
+
+func TestShortestPathOneDirectionalEdge(t *testing.T) {
+	// recorded only as a->b, but ShortestPath treats adjacency as undirected.
+	adjacency := map[string][]string{"a": {"b"}}
+
+	got := ShortestPath(adjacency, "b", "a")
+	want := []string{"b", "a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ShortestPath(b, a) = %v, want %v", got, want)
+	}
+}
+
+func TestNeighborhood(t *testing.T) {
+	// center - a - b, center - c
+	adjacency := map[string][]string{
+		"center": {"a", "c"},
+		"a":      {"b"},
+	}
+
+	for _, tt := range []struct {
+		depth int
+		want  []string
+	}{
+		{depth: 0, want: []string{"center"}},
+		{depth: 1, want: []string{"a", "c", "center"}},
+		{depth: 2, want: []string{"a", "b", "c", "center"}},
+	} {
+		got := Neighborhood(adjacency, "center", tt.depth)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Neighborhood(center, %d) = %v, want %v", tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestNeighborhoodUnknownCenter(t *testing.T) {
+	adjacency := map[string][]string{"a": {"b"}}
+
+	if got := Neighborhood(adjacency, "z", 2); got != nil {
+		t.Errorf("Neighborhood(z, 2) = %v, want nil", got)
+	}
+}
+
+func TestTopologicalLayersDAG(t *testing.T) {
+	// a,b are roots; c depends on both; d depends on c.
+	adjacency := map[string][]string{
+		"a": {"c"},
+		"b": {"c"},
+		"c": {"d"},
+	}
+
+	layers := TopologicalLayers(adjacency)
+	if len(layers) != 3 {
+		t.Fatalf("expected 3 layers, got %d: %v", len(layers), layers)
+	}
+
+	// Membership of each layer is deterministic even though the order
+	// map iteration hands roots to us in isn't, so sort before comparing.
+	got := make([][]string, len(layers))
+	for i, layer := range layers {
+		sorted := append([]string(nil), layer...)
+		sort.Strings(sorted)
+		got[i] = sorted
+	}
+	want := [][]string{{"a", "b"}, {"c"}, {"d"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TopologicalLayers = %v, want %v", got, want)
+	}
+}
+
+func TestTopologicalLayersBreaksCycles(t *testing.T) {
+	// A 3-node cycle has no root, so anyUnplaced must pick an arbitrary
+	// entry point. Which node that is isn't deterministic, but every node
+	// must still end up placed in exactly one layer of its own.
+	adjacency := map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+		"c": {"a"},
+	}
+
+	layers := TopologicalLayers(adjacency)
+	if len(layers) != 3 {
+		t.Fatalf("expected 3 layers, got %d: %v", len(layers), layers)
+	}
+
+	seen := map[string]bool{}
+	for _, layer := range layers {
+		if len(layer) != 1 {
+			t.Fatalf("expected a single-node layer, got %v", layer)
+		}
+		if seen[layer[0]] {
+			t.Fatalf("node %q placed in more than one layer", layer[0])
+		}
+		seen[layer[0]] = true
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if !seen[id] {
+			t.Errorf("node %q never placed in a layer", id)
+		}
+	}
+}
+
+func TestTopologicalLayersEmpty(t *testing.T) {
+	if layers := TopologicalLayers(map[string][]string{}); layers != nil {
+		t.Errorf("TopologicalLayers({}) = %v, want nil", layers)
+	}
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// manyNodes builds a flat, unconnected RenderableNodes set of size n, big
+// enough that re-running Map's MapFunc over it is measurably expensive.
+func manyNodes(n int) RenderableNodes {
+	nodes := make(RenderableNodes, n)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("node-%d", i)
+		nodes[id] = RenderableNode{ID: id}
+	}
+	return nodes
+}
+
+// BenchmarkMapEdgeMetadataUncached shows the quadratic cost of an
+// uncacheable Map: every EdgeMetadata call against a bare Map{} literal
+// re-runs the whole MapFunc pass over the input, so walking e edges costs
+// O(len(nodes) * e) total work.
+func BenchmarkMapEdgeMetadataUncached(b *testing.B) {
+	base := staticRenderer{nodes: manyNodes(1000)}
+	m := Map{MapFunc: identityMapFunc, Renderer: base}
+	rpt := report.Report{}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 50; j++ {
+			m.EdgeMetadata(rpt, "node-0", "node-1")
+		}
+	}
+}
+
+// BenchmarkMapEdgeMetadataCached shows the cacheable case: a Map built
+// with MakeMap, driven through one shared RenderContext, runs the MapFunc
+// pass once and reuses it for every subsequent EdgeMetadataContext call.
+func BenchmarkMapEdgeMetadataCached(b *testing.B) {
+	base := staticRenderer{nodes: manyNodes(1000)}
+	m := MakeMap(identityMapFunc, base)
+	rpt := report.Report{}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := NewRenderContext(rpt)
+		m.RenderContext(ctx)
+		for j := 0; j < 50; j++ {
+			m.EdgeMetadataContext(ctx, "node-0", "node-1")
+		}
+	}
+}
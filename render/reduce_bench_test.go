@@ -0,0 +1,45 @@
+package render
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// slowRenderer simulates a sub-renderer with real work to do, so that
+// BenchmarkReduceRender can show wall-clock scaling rather than just
+// CPU-bound overhead.
+type slowRenderer struct{}
+
+func (slowRenderer) Render(report.Report) RenderableNodes {
+	time.Sleep(time.Millisecond)
+	return RenderableNodes{}
+}
+
+func (slowRenderer) EdgeMetadata(report.Report, string, string) report.EdgeMetadata {
+	time.Sleep(time.Millisecond)
+	return report.EdgeMetadata{}
+}
+
+// BenchmarkReduceRender demonstrates that Reduce.Render's wall-clock cost
+// doesn't grow linearly with the number of sub-renderers: renderer counts
+// up to reduceConcurrency complete in about the time a single renderer
+// takes, rather than renderers*renderTime.
+func BenchmarkReduceRender(b *testing.B) {
+	for _, n := range []int{1, 2, 4, 8, 16, 32} {
+		b.Run(fmt.Sprintf("renderers=%d", n), func(b *testing.B) {
+			renderers := make([]Renderer, n)
+			for i := range renderers {
+				renderers[i] = slowRenderer{}
+			}
+			reduce := MakeReduce(renderers...)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				reduce.Render(report.Report{})
+			}
+		})
+	}
+}
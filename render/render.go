@@ -1,17 +1,60 @@
 package render
 
 import (
+	"context"
 	"log"
+	"sync"
+	"sync/atomic"
 
+	"github.com/weaveworks/scope/render/graph"
 	"github.com/weaveworks/scope/report"
 )
 
+// reduceConcurrency bounds how many sub-renderers a Reduce will run at
+// once, so that a Reduce over many topologies doesn't spawn unbounded
+// goroutines.
+const reduceConcurrency = 8
+
 // Renderer is something that can render a report to a set of RenderableNodes.
 type Renderer interface {
 	Render(report.Report) RenderableNodes
 	EdgeMetadata(rpt report.Report, localID, remoteID string) report.EdgeMetadata
 }
 
+// RenderContext carries a report through a sequence of Render/EdgeMetadata
+// calls, along with a cache that lets a Renderer reuse work it did for an
+// earlier call against the same report. Callers that only need a single
+// Render can ignore it; NewRenderContext and plain Render/EdgeMetadata
+// calls create one per call, which is equivalent to having no cache at all.
+type RenderContext struct {
+	report.Report
+	Context context.Context
+	cache   *sync.Map
+}
+
+// NewRenderContext creates a RenderContext for rpt with a fresh cache and
+// context.Background() as its cancellation context.
+func NewRenderContext(rpt report.Report) RenderContext {
+	return NewRenderContextWithCancel(context.Background(), rpt)
+}
+
+// NewRenderContextWithCancel creates a RenderContext for rpt whose
+// sub-renders can be cancelled via ctx, e.g. when the HTTP request that
+// triggered the render is aborted.
+func NewRenderContextWithCancel(ctx context.Context, rpt report.Report) RenderContext {
+	return RenderContext{Report: rpt, Context: ctx, cache: &sync.Map{}}
+}
+
+// ContextRenderer is a Renderer that can take part in a shared
+// RenderContext, so that repeated calls against the same report (e.g. a
+// Render followed by many EdgeMetadata calls from the UI walking the
+// resulting graph) don't each re-run the underlying Renderer chain.
+type ContextRenderer interface {
+	Renderer
+	RenderContext(RenderContext) RenderableNodes
+	EdgeMetadataContext(ctx RenderContext, localID, remoteID string) report.EdgeMetadata
+}
+
 // Reduce renderer is a Renderer which merges together the output of several
 // other renderers.
 type Reduce []Renderer
@@ -23,38 +66,169 @@ func MakeReduce(renderers ...Renderer) Renderer {
 
 // Render produces a set of RenderableNodes given a Report.
 func (r Reduce) Render(rpt report.Report) RenderableNodes {
+	return r.RenderContext(NewRenderContext(rpt))
+}
+
+// EdgeMetadata produces an EdgeMetadata for a given edge.
+func (r Reduce) EdgeMetadata(rpt report.Report, localID, remoteID string) report.EdgeMetadata {
+	return r.EdgeMetadataContext(NewRenderContext(rpt), localID, remoteID)
+}
+
+// RenderContext implements ContextRenderer. It fans the sub-renderers out
+// across a bounded pool of goroutines, passing ctx down to each so its
+// cache is shared across them, and folds their results back together in
+// renderer order once all of them have either finished or been cancelled
+// via ctx.Context - so the merged output is stable across runs regardless
+// of which sub-renderer happens to finish first.
+func (r Reduce) RenderContext(ctx RenderContext) RenderableNodes {
+	results := make([]RenderableNodes, len(r))
+	r.fanOut(ctx, func(i int, renderer Renderer) {
+		results[i] = renderWithContext(renderer, ctx)
+	})
+
 	result := RenderableNodes{}
-	for _, renderer := range r {
-		result.Merge(renderer.Render(rpt))
+	for _, nodes := range results {
+		result.Merge(nodes)
 	}
 	return result
 }
 
-// EdgeMetadata produces an EdgeMetadata for a given edge.
-func (r Reduce) EdgeMetadata(rpt report.Report, localID, remoteID string) report.EdgeMetadata {
+// EdgeMetadataContext implements ContextRenderer, fanning out and folding
+// in the same way as RenderContext.
+func (r Reduce) EdgeMetadataContext(ctx RenderContext, localID, remoteID string) report.EdgeMetadata {
+	results := make([]report.EdgeMetadata, len(r))
+	r.fanOut(ctx, func(i int, renderer Renderer) {
+		results[i] = edgeMetadataWithContext(renderer, ctx, localID, remoteID)
+	})
+
 	metadata := report.EdgeMetadata{}
-	for _, renderer := range r {
-		metadata = metadata.Merge(renderer.EdgeMetadata(rpt, localID, remoteID))
+	for _, m := range results {
+		metadata = metadata.Merge(m)
 	}
 	return metadata
 }
 
+// fanOut runs work(i, r[i]) for every sub-renderer over a bounded pool of
+// goroutines, returning once they have all either completed or been
+// abandoned because ctx.Context was cancelled.
+func (r Reduce) fanOut(ctx RenderContext, work func(i int, renderer Renderer)) {
+	sem := make(chan struct{}, reduceConcurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for i, renderer := range r {
+		select {
+		case <-ctx.Context.Done():
+			// Stop scheduling new work, but still wait (via the
+			// deferred wg.Wait above) for goroutines already
+			// started, since they write into the caller's
+			// results slice and must finish before it's read.
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int, renderer Renderer) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if ctx.Context.Err() != nil {
+				return
+			}
+			work(i, renderer)
+		}(i, renderer)
+	}
+}
+
+// renderWithContext calls RenderContext on renderer if it is a
+// ContextRenderer, falling back to a plain Render otherwise.
+func renderWithContext(renderer Renderer, ctx RenderContext) RenderableNodes {
+	if cr, ok := renderer.(ContextRenderer); ok {
+		return cr.RenderContext(ctx)
+	}
+	return renderer.Render(ctx.Report)
+}
+
+// edgeMetadataWithContext calls EdgeMetadataContext on renderer if it is a
+// ContextRenderer, falling back to a plain EdgeMetadata otherwise.
+func edgeMetadataWithContext(renderer Renderer, ctx RenderContext, localID, remoteID string) report.EdgeMetadata {
+	if cr, ok := renderer.(ContextRenderer); ok {
+		return cr.EdgeMetadataContext(ctx, localID, remoteID)
+	}
+	return renderer.EdgeMetadata(ctx.Report, localID, remoteID)
+}
+
 // Map is a Renderer which produces a set of RenderableNodes from the set of
 // RenderableNodes produced by another Renderer.
+//
+// Map's methods use value receivers, like every other Renderer in this
+// file, so a bare Map{MapFunc: ..., Renderer: ...} literal still satisfies
+// Renderer by value. Memoization (see RenderContext below) needs a stable
+// identity to key its cache on, which a value receiver can't derive from
+// &m - that's a different address on every call. MakeMap solves this by
+// stamping a unique, immutable cacheKey onto the value at construction
+// time; Maps built directly as a literal get the zero cacheKey, which
+// render() treats as "uncacheable" and simply recomputes on every call.
 type Map struct {
 	MapFunc
 	Renderer
+	cacheKey uint64
+}
+
+var mapCacheKeys uint64
+
+// MakeMap wraps r with mapFunc as a Map renderer that can memoize its
+// (output, mapped) pair per RenderContext. Prefer this over a bare Map{}
+// literal whenever the Map will be driven through a shared RenderContext.
+func MakeMap(mapFunc MapFunc, r Renderer) Map {
+	return Map{MapFunc: mapFunc, Renderer: r, cacheKey: atomic.AddUint64(&mapCacheKeys, 1)}
+}
+
+type mapCacheEntry struct {
+	output RenderableNodes
+	mapped map[string]string
 }
 
 // Render transforms a set of RenderableNodes produces by another Renderer.
 // using a map function
 func (m Map) Render(rpt report.Report) RenderableNodes {
-	output, _ := m.render(rpt)
+	return m.RenderContext(NewRenderContext(rpt))
+}
+
+// RenderContext implements ContextRenderer. If m has a cacheKey (i.e. it
+// was built with MakeMap), the (output, mapped) pair it computes is
+// cached on ctx, so a later EdgeMetadataContext call against the same ctx
+// reuses it instead of re-running the Renderer chain.
+func (m Map) RenderContext(ctx RenderContext) RenderableNodes {
+	output, _ := m.render(ctx)
 	return output
 }
 
-func (m Map) render(rpt report.Report) (RenderableNodes, map[string]string) {
-	input := m.Renderer.Render(rpt)
+// EdgeMetadata gives the metadata of an edge from the perspective of the
+// srcRenderableID, via a fresh, single-use RenderContext.
+func (m Map) EdgeMetadata(rpt report.Report, srcRenderableID, dstRenderableID string) report.EdgeMetadata {
+	return m.EdgeMetadataContext(NewRenderContext(rpt), srcRenderableID, dstRenderableID)
+}
+
+// render computes (or, if ctx already has it cached, reuses) the
+// (output, mapped) pair for this Map against ctx's report.
+func (m Map) render(ctx RenderContext) (RenderableNodes, map[string]string) {
+	cacheable := m.cacheKey != 0 && ctx.cache != nil
+	if cacheable {
+		if cached, ok := ctx.cache.Load(m.cacheKey); ok {
+			entry := cached.(mapCacheEntry)
+			return entry.output, entry.mapped
+		}
+	}
+
+	output, mapped := m.renderUncached(renderWithContext(m.Renderer, ctx))
+
+	if cacheable {
+		ctx.cache.Store(m.cacheKey, mapCacheEntry{output: output, mapped: mapped})
+	}
+	return output, mapped
+}
+
+func (m Map) renderUncached(input RenderableNodes) (RenderableNodes, map[string]string) {
 	output := RenderableNodes{}
 	mapped := map[string]string{}             // input node ID -> output node ID
 	adjacencies := map[string]report.IDList{} // output node ID -> input node Adjacencies
@@ -94,13 +268,14 @@ func (m Map) render(rpt report.Report) (RenderableNodes, map[string]string) {
 	return output, mapped
 }
 
-// EdgeMetadata gives the metadata of an edge from the perspective of the
-// srcRenderableID. Since an edgeID can have multiple edges on the address
-// level, it uses the supplied mapping function to translate address IDs to
-// renderable node (mapped) IDs.
-func (m Map) EdgeMetadata(rpt report.Report, srcRenderableID, dstRenderableID string) report.EdgeMetadata {
+// EdgeMetadataContext gives the metadata of an edge from the perspective of
+// the srcRenderableID. Since an edgeID can have multiple edges on the
+// address level, it uses the supplied mapping function to translate
+// address IDs to renderable node (mapped) IDs. It reuses ctx's cached
+// (output, mapped) pair rather than re-running the Renderer chain.
+func (m Map) EdgeMetadataContext(ctx RenderContext, srcRenderableID, dstRenderableID string) report.EdgeMetadata {
 	// First we need to map the ids in this layer into the ids in the underlying layer
-	_, mapped := m.render(rpt)        // this maps from old -> new
+	_, mapped := m.render(ctx)         // this maps from old -> new
 	inverted := map[string][]string{} // this maps from new -> old(s)
 	for k, v := range mapped {
 		existing := inverted[v]
@@ -119,7 +294,7 @@ func (m Map) EdgeMetadata(rpt report.Report, srcRenderableID, dstRenderableID st
 	// Now recurse for each old edge
 	output := report.EdgeMetadata{}
 	for _, edge := range oldEdges {
-		metadata := m.Renderer.EdgeMetadata(rpt, edge.src, edge.dst)
+		metadata := edgeMetadataWithContext(m.Renderer, ctx, edge.src, edge.dst)
 		output = output.Merge(metadata)
 	}
 	return output
@@ -138,6 +313,11 @@ type LeafMap struct {
 // always be rendered with other nodes, and therefore contains limited detail.
 //
 // Nodes with the same mapped IDs will be merged.
+//
+// LeafMap has no sub-renderer to memoize, so RenderContext/
+// EdgeMetadataContext below just unwrap ctx and defer to Render/
+// EdgeMetadata; it implements ContextRenderer purely so that it can sit
+// anywhere in a Renderer tree without breaking a parent's context-sharing.
 func (m LeafMap) Render(rpt report.Report) RenderableNodes {
 	var (
 		t             = m.Selector(rpt)
@@ -269,6 +449,16 @@ func (m LeafMap) EdgeMetadata(rpt report.Report, srcRenderableID, dstRenderableI
 	return metadata
 }
 
+// RenderContext implements ContextRenderer.
+func (m LeafMap) RenderContext(ctx RenderContext) RenderableNodes {
+	return m.Render(ctx.Report)
+}
+
+// EdgeMetadataContext implements ContextRenderer.
+func (m LeafMap) EdgeMetadataContext(ctx RenderContext, srcRenderableID, dstRenderableID string) report.EdgeMetadata {
+	return m.EdgeMetadata(ctx.Report, srcRenderableID, dstRenderableID)
+}
+
 // FilterUnconnected is a Renderer which filters out unconnected nodes.
 type FilterUnconnected struct {
 	Renderer
@@ -279,18 +469,41 @@ func (f FilterUnconnected) Render(rpt report.Report) RenderableNodes {
 	return OnlyConnected(f.Renderer.Render(rpt))
 }
 
-// OnlyConnected filters out unconnected RenderedNodes
+// RenderContext implements ContextRenderer, reusing f.Renderer's cached
+// output via ctx rather than calling its plain Render.
+func (f FilterUnconnected) RenderContext(ctx RenderContext) RenderableNodes {
+	return OnlyConnected(renderWithContext(f.Renderer, ctx))
+}
+
+// EdgeMetadataContext implements ContextRenderer.
+func (f FilterUnconnected) EdgeMetadataContext(ctx RenderContext, localID, remoteID string) report.EdgeMetadata {
+	return edgeMetadataWithContext(f.Renderer, ctx, localID, remoteID)
+}
+
+// OnlyConnected filters out unconnected RenderedNodes. It's a degenerate
+// case of graph.ConnectedComponents: a node with no edges to the rest of
+// the graph is just a connected component of size 1, so dropping
+// "unconnected" nodes means dropping every component that small - except a size-1 component whose one node has a self-loop, which
+// does have an edge (to itself) and so counts as connected.
 func OnlyConnected(input RenderableNodes) RenderableNodes {
 	output := RenderableNodes{}
-	for id, node := range input {
-		if len(node.Adjacency) == 0 {
+	for _, component := range graph.ConnectedComponents(adjacencyOf(input)) {
+		if len(component) == 1 && !hasSelfLoop(input, component[0]) {
 			continue
 		}
-
-		output[id] = node
-		for _, id := range node.Adjacency {
+		for _, id := range component {
 			output[id] = input[id]
 		}
 	}
 	return output
 }
+
+// hasSelfLoop reports whether id's own Adjacency list includes id itself.
+func hasSelfLoop(nodes RenderableNodes, id string) bool {
+	for _, adjID := range nodes[id].Adjacency {
+		if adjID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package render
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// ReverseDNSNames is the node metadata key under which ReverseDNSRenderer
+// stores the names it resolved for a node's IP.
+const ReverseDNSNames = "reverse_dns_names"
+
+// Resolver looks up the names for an IP address. Its method has the same
+// signature as net.LookupAddr; tests supply a stub.
+type Resolver interface {
+	LookupAddr(addr string) (names []string, err error)
+}
+
+// ReverseDNSRenderer is a Renderer which wraps another Renderer and
+// annotates pseudo/Internet nodes with names found via reverse DNS lookup,
+// for the case where the probe itself wasn't able to resolve them. Lookups
+// are performed asynchronously against a bounded worker pool and cached
+// with a TTL, so the first render of a fresh IP returns it unannotated and
+// later renders pick up the resolved name(s) once the lookup completes.
+type ReverseDNSRenderer struct {
+	Renderer
+	resolver Resolver
+	cache    *dnsCache
+	work     chan string
+	stopOnce sync.Once
+}
+
+// MakeReverseDNSRenderer wraps r with reverse DNS enrichment. workers
+// bounds the number of concurrent lookups, and ttl bounds how long a
+// resolved (or failed) answer is cached before it is looked up again.
+// The caller must call Stop when the renderer is no longer needed, to
+// shut down its worker pool.
+func MakeReverseDNSRenderer(r Renderer, resolver Resolver, workers int, capacity int, ttl time.Duration) *ReverseDNSRenderer {
+	rd := &ReverseDNSRenderer{
+		Renderer: r,
+		resolver: resolver,
+		cache:    newDNSCache(capacity, ttl),
+		work:     make(chan string, capacity),
+	}
+	for i := 0; i < workers; i++ {
+		go rd.loop()
+	}
+	return rd
+}
+
+// Stop shuts down rd's worker pool. It is safe to call more than once, but
+// Render must not be called during or after Stop: an enqueue would then
+// send on the now-closed work channel and panic.
+func (rd *ReverseDNSRenderer) Stop() {
+	rd.stopOnce.Do(func() { close(rd.work) })
+}
+
+// Render implements Renderer.
+func (rd *ReverseDNSRenderer) Render(rpt report.Report) RenderableNodes {
+	nodes := rd.Renderer.Render(rpt)
+	output := make(RenderableNodes, len(nodes))
+	for id, node := range nodes {
+		if addr, ok := pseudoNodeAddr(node); ok {
+			if names, ok := rd.cache.get(addr); ok {
+				node.Metadata = node.Metadata.Copy()
+				node.Metadata[ReverseDNSNames] = joinNames(names)
+			} else {
+				rd.enqueue(addr)
+			}
+		}
+		output[id] = node
+	}
+	return output
+}
+
+// enqueue schedules addr for lookup, dropping the request rather than
+// blocking the render path if the work queue is full.
+func (rd *ReverseDNSRenderer) enqueue(addr string) {
+	if rd.cache.markPending(addr) {
+		select {
+		case rd.work <- addr:
+		default:
+			rd.cache.clearPending(addr)
+		}
+	}
+}
+
+func (rd *ReverseDNSRenderer) loop() {
+	for addr := range rd.work {
+		names, err := rd.resolver.LookupAddr(addr)
+		if err != nil {
+			names = nil
+		}
+		rd.cache.put(addr, names)
+	}
+}
+
+// pseudoNodeAddr returns the IP address a pseudo/Internet node (or any
+// node one of whose Origins is an address node, i.e. an endpoint known
+// only by its IP) should be looked up under.
+func pseudoNodeAddr(node RenderableNode) (string, bool) {
+	if node.Pseudo {
+		if addr, ok := report.ParseAddressNodeID(node.ID); ok {
+			return addr, true
+		}
+	}
+	for _, origin := range node.Origins {
+		if addr, ok := report.ParseAddressNodeID(origin); ok {
+			return addr, true
+		}
+	}
+	return "", false
+}
+
+// joinNames turns a reverse DNS lookup's resolved names into the single
+// string value ReverseDNSNames is stored as, matching the comma-separated
+// convention RenderableNode metadata already uses for other multi-value
+// fields.
+func joinNames(names []string) string {
+	return strings.Join(names, ", ")
+}
+
+// dnsCache is a bounded, TTL'd LRU cache of reverse DNS answers, with
+// in-flight tracking so a given address is queued at most once at a time. order
+// holds cached addresses from least- to most-recently-used; get and put
+// both move an address to the most-recently-used end, so it's recency of
+// use, not just insertion order, that decides which entry is evicted when
+// the cache is over capacity.
+type dnsCache struct {
+	mtx      sync.Mutex
+	ttl      time.Duration
+	capacity int
+	entries  map[string]dnsCacheEntry
+	pending  map[string]struct{}
+	order    []string
+}
+
+type dnsCacheEntry struct {
+	names   []string
+	expires time.Time
+}
+
+func newDNSCache(capacity int, ttl time.Duration) *dnsCache {
+	return &dnsCache{
+		ttl:      ttl,
+		capacity: capacity,
+		entries:  map[string]dnsCacheEntry{},
+		pending:  map[string]struct{}{},
+	}
+}
+
+func (c *dnsCache) get(addr string) ([]string, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	entry, ok := c.entries[addr]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	c.touch(addr)
+	return entry.names, true
+}
+
+// touch marks addr as the most-recently-used entry in order. Callers must
+// hold c.mtx.
+func (c *dnsCache) touch(addr string) {
+	for i, id := range c.order {
+		if id == addr {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, addr)
+}
+
+func (c *dnsCache) markPending(addr string) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.pending[addr]; ok {
+		return false
+	}
+	c.pending[addr] = struct{}{}
+	return true
+}
+
+func (c *dnsCache) clearPending(addr string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.pending, addr)
+}
+
+func (c *dnsCache) put(addr string, names []string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.pending, addr)
+	if _, exists := c.entries[addr]; exists {
+		c.touch(addr)
+	} else {
+		c.order = append(c.order, addr)
+		for len(c.order) > c.capacity {
+			oldest := c.order[0]
+			c.order = c.order[1:]
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[addr] = dnsCacheEntry{names: names, expires: time.Now().Add(c.ttl)}
+}
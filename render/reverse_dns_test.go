@@ -0,0 +1,114 @@
+package render
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// stubResolver answers from a fixed map, and counts lookups so tests can
+// assert the cache is actually preventing repeat work.
+type stubResolver struct {
+	names   map[string][]string
+	lookups int
+}
+
+func (r *stubResolver) LookupAddr(addr string) ([]string, error) {
+	r.lookups++
+	return r.names[addr], nil
+}
+
+func waitForCacheEntry(t *testing.T, rd *ReverseDNSRenderer, addr string) []string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if names, ok := rd.cache.get(addr); ok {
+			return names
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %q to be resolved", addr)
+	return nil
+}
+
+func TestReverseDNSRendererResolvesPseudoNode(t *testing.T) {
+	resolver := &stubResolver{names: map[string][]string{"1.2.3.4": {"example.com"}}}
+	base := staticRenderer{nodes: RenderableNodes{
+		"in;1.2.3.4": {ID: "in;1.2.3.4", Pseudo: true},
+	}}
+	rd := MakeReverseDNSRenderer(base, resolver, 1, 10, time.Minute)
+	defer rd.Stop()
+
+	rd.Render(report.Report{})
+	waitForCacheEntry(t, rd, "1.2.3.4")
+
+	output := rd.Render(report.Report{})
+	if got, want := output["in;1.2.3.4"].Metadata[ReverseDNSNames], "example.com"; got != want {
+		t.Errorf("ReverseDNSNames = %q, want %q", got, want)
+	}
+}
+
+func TestReverseDNSRendererResolvesAddressOrigin(t *testing.T) {
+	resolver := &stubResolver{names: map[string][]string{"5.6.7.8": {"host.example.com"}}}
+	base := staticRenderer{nodes: RenderableNodes{
+		"group": {ID: "group", Origins: report.MakeIDList().Add("5.6.7.8")},
+	}}
+	rd := MakeReverseDNSRenderer(base, resolver, 1, 10, time.Minute)
+	defer rd.Stop()
+
+	rd.Render(report.Report{})
+	waitForCacheEntry(t, rd, "5.6.7.8")
+
+	output := rd.Render(report.Report{})
+	if got, want := output["group"].Metadata[ReverseDNSNames], "host.example.com"; got != want {
+		t.Errorf("ReverseDNSNames = %q, want %q", got, want)
+	}
+}
+
+func TestReverseDNSRendererIgnoresOrdinaryNodes(t *testing.T) {
+	resolver := &stubResolver{}
+	base := staticRenderer{nodes: RenderableNodes{"a": {ID: "a"}}}
+	rd := MakeReverseDNSRenderer(base, resolver, 1, 10, time.Minute)
+	defer rd.Stop()
+
+	output := rd.Render(report.Report{})
+
+	if _, ok := output["a"].Metadata[ReverseDNSNames]; ok {
+		t.Errorf("expected no ReverseDNSNames set on an ordinary node")
+	}
+	if resolver.lookups != 0 {
+		t.Errorf("expected no lookups for an ordinary node, got %d", resolver.lookups)
+	}
+}
+
+func TestReverseDNSRendererStopIsIdempotent(t *testing.T) {
+	rd := MakeReverseDNSRenderer(staticRenderer{}, &stubResolver{}, 1, 10, time.Minute)
+	rd.Stop()
+	rd.Stop()
+}
+
+func TestDNSCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newDNSCache(2, time.Minute)
+	cache.put("a", []string{"a.example.com"})
+	cache.put("b", []string{"b.example.com"})
+
+	// Touch "a" so it's more recently used than "b".
+	if _, ok := cache.get("a"); !ok {
+		t.Fatalf("expected %q to be cached", "a")
+	}
+
+	// Adding a third entry should evict "b", the least-recently-used,
+	// not "a", which was the first one inserted.
+	cache.put("c", []string{"c.example.com"})
+
+	if _, ok := cache.get("a"); !ok {
+		t.Errorf("expected recently-used %q to survive eviction", "a")
+	}
+	if _, ok := cache.get("b"); ok {
+		t.Errorf("expected least-recently-used %q to be evicted", "b")
+	}
+	if _, ok := cache.get("c"); !ok {
+		t.Errorf("expected newly-inserted %q to be cached", "c")
+	}
+}
@@ -0,0 +1,108 @@
+package render
+
+import (
+	"strconv"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// SamplingCompensator is a Renderer which wraps another Renderer and
+// inflates the counter fields of its output to compensate for sampling.
+// The probe normally does this itself (see interpolateCounts), but reports
+// from third-party ingestors can arrive un-interpolated, so the app needs
+// to be able to opt into doing the same compensation at render time.
+type SamplingCompensator struct {
+	Renderer
+	CounterKeys []string
+}
+
+// MakeSamplingCompensator wraps r so that its rendered EdgeMetadata, plus
+// any node Metadata keyed by counterKeys, is scaled by
+// 1/report.Sampling.Rate(). counterKeys names the node-level metadata
+// fields that accumulate over time and so should be treated as counters;
+// a gauge such as an open connection count should not be named here, or
+// it will be inflated as if it were a counter. Scaling is skipped when the
+// rate is 0 or 1 (meaning the report is already exhaustive, or carries no
+// usable sampling hint at all).
+func MakeSamplingCompensator(r Renderer, counterKeys ...string) Renderer {
+	return SamplingCompensator{Renderer: r, CounterKeys: counterKeys}
+}
+
+// Render implements Renderer.
+func (c SamplingCompensator) Render(rpt report.Report) RenderableNodes {
+	nodes := c.Renderer.Render(rpt)
+	factor := compensationFactor(rpt.Sampling)
+	if factor == 1 {
+		return nodes
+	}
+
+	output := make(RenderableNodes, len(nodes))
+	for id, node := range nodes {
+		node.EdgeMetadata = scaleEdgeMetadata(node.EdgeMetadata, factor)
+		node.Metadata = scaleNodeCounters(node.Metadata, factor, c.CounterKeys)
+		output[id] = node
+	}
+	return output
+}
+
+// EdgeMetadata implements Renderer.
+func (c SamplingCompensator) EdgeMetadata(rpt report.Report, localID, remoteID string) report.EdgeMetadata {
+	metadata := c.Renderer.EdgeMetadata(rpt, localID, remoteID)
+	return scaleEdgeMetadata(metadata, compensationFactor(rpt.Sampling))
+}
+
+// compensationFactor returns the multiplier that undoes sampling for a
+// report. A rate of 0 or 1 means the report needs no compensation - either
+// it wasn't sampled, or we have no usable rate to compensate with.
+func compensationFactor(s report.Sampling) float64 {
+	rate := s.Rate()
+	if rate <= 0 || rate >= 1 {
+		return 1
+	}
+	return 1 / rate
+}
+
+// scaleEdgeMetadata multiplies md's counter fields - the byte and packet
+// counts, which accumulate over the life of the edge - by factor. Gauge
+// fields such as MaxConnCountTCP, which describe an instantaneous value
+// rather than an accumulated count, are left untouched: inflating a
+// sampled count is correct, inflating a gauge is not.
+func scaleEdgeMetadata(md report.EdgeMetadata, factor float64) report.EdgeMetadata {
+	if factor == 1 {
+		return md
+	}
+	md.EgressByteCount = scaleCount(md.EgressByteCount, factor)
+	md.IngressByteCount = scaleCount(md.IngressByteCount, factor)
+	md.EgressPacketCount = scaleCount(md.EgressPacketCount, factor)
+	md.IngressPacketCount = scaleCount(md.IngressPacketCount, factor)
+	return md
+}
+
+func scaleCount(count uint64, factor float64) uint64 {
+	return uint64(float64(count) * factor)
+}
+
+// scaleNodeCounters scales the metadata entries named by counterKeys by
+// factor, leaving every other entry - including any gauge the caller chose
+// not to name - untouched. Entries that are missing or don't parse as an
+// unsigned integer are left as-is rather than causing the whole node to be
+// dropped.
+func scaleNodeCounters(metadata report.Metadata, factor float64, counterKeys []string) report.Metadata {
+	if len(counterKeys) == 0 || len(metadata) == 0 {
+		return metadata
+	}
+
+	metadata = metadata.Copy()
+	for _, key := range counterKeys {
+		raw, ok := metadata[key]
+		if !ok {
+			continue
+		}
+		count, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		metadata[key] = strconv.FormatUint(scaleCount(count, factor), 10)
+	}
+	return metadata
+}
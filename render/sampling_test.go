@@ -0,0 +1,153 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// staticRenderer is a Renderer that always returns the same nodes,
+// regardless of the report passed in - enough to exercise wrapping
+// Renderers like SamplingCompensator without needing a real report.
+type staticRenderer struct {
+	nodes RenderableNodes
+}
+
+func (s staticRenderer) Render(report.Report) RenderableNodes { return s.nodes }
+
+func (s staticRenderer) EdgeMetadata(_ report.Report, localID, _ string) report.EdgeMetadata {
+	if node, ok := s.nodes[localID]; ok {
+		return node.EdgeMetadata
+	}
+	return report.EdgeMetadata{}
+}
+
+// sampledReport returns a report sampled at 1 in 10, i.e. Sampling.Rate()
+// == 0.1, so compensation should inflate counters by 10x.
+func sampledReport() report.Report {
+	return report.Report{Sampling: report.Sampling{Count: 1, Total: 10}}
+}
+
+// identityMapFunc is a MapFunc that passes every RenderableNode through
+// unchanged; used wherever a test needs a Map but not its mapping.
+func identityMapFunc(n RenderableNode) (RenderableNode, bool) { return n, true }
+
+func edgeNode(id string, bytes, packets uint64) RenderableNode {
+	return RenderableNode{
+		ID: id,
+		EdgeMetadata: report.EdgeMetadata{
+			EgressByteCount:    bytes,
+			IngressByteCount:   bytes,
+			EgressPacketCount:  packets,
+			IngressPacketCount: packets,
+		},
+	}
+}
+
+func TestSamplingCompensatorReduce(t *testing.T) {
+	base := staticRenderer{nodes: RenderableNodes{"a": edgeNode("a", 100, 10)}}
+	compensator := MakeSamplingCompensator(MakeReduce(base))
+
+	output := compensator.Render(sampledReport())
+
+	if got, want := output["a"].EdgeMetadata.EgressByteCount, uint64(1000); got != want {
+		t.Errorf("Reduce: EgressByteCount = %d, want %d", got, want)
+	}
+	if got, want := output["a"].EdgeMetadata.EgressPacketCount, uint64(100); got != want {
+		t.Errorf("Reduce: EgressPacketCount = %d, want %d", got, want)
+	}
+}
+
+func TestSamplingCompensatorMap(t *testing.T) {
+	base := staticRenderer{nodes: RenderableNodes{"a": edgeNode("a", 100, 10)}}
+	m := MakeMap(identityMapFunc, base)
+	compensator := MakeSamplingCompensator(m)
+
+	output := compensator.Render(sampledReport())
+
+	if got, want := output["a"].EdgeMetadata.IngressByteCount, uint64(1000); got != want {
+		t.Errorf("Map: IngressByteCount = %d, want %d", got, want)
+	}
+}
+
+func TestSamplingCompensatorLeafMap(t *testing.T) {
+	topology := report.Topology{
+		NodeMetadatas: report.NodeMetadatas{
+			"ep;a": {Metadata: map[string]string{"id": "a"}},
+			"ep;b": {Metadata: map[string]string{"id": "b"}},
+		},
+		EdgeMetadatas: report.EdgeMetadatas{
+			report.MakeEdgeID("ep;a", "ep;b"): {
+				EgressByteCount:    100,
+				IngressByteCount:   100,
+				EgressPacketCount:  10,
+				IngressPacketCount: 10,
+			},
+		},
+	}
+
+	leafMap := LeafMap{
+		Selector: func(report.Report) report.Topology { return topology },
+		Mapper: func(n report.NodeMetadata) (RenderableNode, bool) {
+			id := n.Metadata["id"]
+			if id == "" {
+				return RenderableNode{}, false
+			}
+			return RenderableNode{ID: id}, true
+		},
+	}
+	compensator := MakeSamplingCompensator(leafMap)
+
+	metadata := compensator.EdgeMetadata(sampledReport(), "a", "b")
+
+	if got, want := metadata.EgressByteCount, uint64(1000); got != want {
+		t.Errorf("LeafMap: EgressByteCount = %d, want %d", got, want)
+	}
+	if got, want := metadata.EgressPacketCount, uint64(100); got != want {
+		t.Errorf("LeafMap: EgressPacketCount = %d, want %d", got, want)
+	}
+}
+
+func TestSamplingCompensatorSkipsUnsampledReports(t *testing.T) {
+	base := staticRenderer{nodes: RenderableNodes{"a": edgeNode("a", 100, 10)}}
+	compensator := MakeSamplingCompensator(base)
+
+	output := compensator.Render(report.Report{})
+
+	if got, want := output["a"].EdgeMetadata.EgressByteCount, uint64(100); got != want {
+		t.Errorf("unsampled report: EgressByteCount = %d, want %d (unchanged)", got, want)
+	}
+}
+
+func TestSamplingCompensatorScalesNamedNodeCounters(t *testing.T) {
+	node := RenderableNode{
+		ID: "a",
+		Metadata: report.Metadata{
+			"open_files":  "7",
+			"connections": "12",
+		},
+	}
+	base := staticRenderer{nodes: RenderableNodes{"a": node}}
+	compensator := MakeSamplingCompensator(base, "connections")
+
+	output := compensator.Render(sampledReport())
+
+	if got, want := output["a"].Metadata["connections"], "120"; got != want {
+		t.Errorf("connections = %q, want %q", got, want)
+	}
+	if got, want := output["a"].Metadata["open_files"], "7"; got != want {
+		t.Errorf("open_files (not a named counter) = %q, want %q (unchanged)", got, want)
+	}
+}
+
+func TestSamplingCompensatorLeavesUnnamedNodeCountersAlone(t *testing.T) {
+	node := RenderableNode{ID: "a", Metadata: report.Metadata{"open_conn_count": "3"}}
+	base := staticRenderer{nodes: RenderableNodes{"a": node}}
+	compensator := MakeSamplingCompensator(base)
+
+	output := compensator.Render(sampledReport())
+
+	if got, want := output["a"].Metadata["open_conn_count"], "3"; got != want {
+		t.Errorf("gauge with no CounterKeys configured = %q, want %q (unchanged)", got, want)
+	}
+}